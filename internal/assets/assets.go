@@ -0,0 +1,27 @@
+// Package assets embeds the runtime assets shipped inside the mdflash binary: a
+// default deck template new users can start from, and the CSS/HTML stylesheet used
+// by the optional HTML export (see ExportHTML). Localized UI strings were dropped
+// from this package's scope: the CLI's prompts (files.go, utils.go) aren't routed
+// through any i18n layer yet, so embedding locale files with nothing reading them
+// would just be more dead weight.
+package assets
+
+import "embed"
+
+//go:embed templates
+var templatesFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// DefaultDeckTemplate returns the contents of the starter deck template shipped
+// inside the binary, used to scaffold a new deck file.
+func DefaultDeckTemplate() ([]byte, error) {
+	return templatesFS.ReadFile("templates/default.md")
+}
+
+// Stylesheet returns the contents of the named static asset (e.g. "style.css" or
+// "template.html") used by ExportHTML.
+func Stylesheet(name string) ([]byte, error) {
+	return staticFS.ReadFile("static/" + name)
+}
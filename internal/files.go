@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"slices"
@@ -13,32 +14,57 @@ import (
 	"time"
 
 	gonanoid "github.com/matoous/go-nanoid"
+
+	"github.com/falleng0d/markdown-flashcards/internal/assets"
 )
 
-// getMetadata extracts the metadata (ID, box, due date, enabled; embedded in html comment tag) from a line.
-// Supported forms:
-//   <!--ID;box;YYYY-MM-DD-->
-//   <!--ID;box;YYYY-MM-DD;true|false-->
-func getMetadata(line string) (id, box, due string, enabled bool) {
-	re := regexp.MustCompile(`<!--\s*(.{4});(\d);(\d{4}-\d{2}-\d{2})(?:;(true|false))?\s*-->`)
-	matches := re.FindStringSubmatch(line)
+// metadataRe matches the metadata comment tag. Supported forms, oldest to newest:
+//
+//	<!--ID;box;YYYY-MM-DD-->
+//	<!--ID;box;YYYY-MM-DD;true|false-->
+//	<!--ID;box;YYYY-MM-DD;true|false;HASH-->
+//	<!--ID;box;YYYY-MM-DD;true|false;HASH;sm2;EF;n;I-->
+//
+// Every group after the due date is optional, so each older form still parses, with
+// sensible defaults (enabled, no stored hash, the Leitner scheduler) for an absent
+// group. A tag carrying fields beyond the newest form does not match at all.
+var metadataRe = regexp.MustCompile(`<!--\s*(.{4});(\d+);(\d{4}-\d{2}-\d{2})(?:;(true|false))?(?:;([0-9a-f]{12}))?(?:;(sm2);([\d.]+);(\d+);(\d+))?\s*-->`)
+
+// getMetadata extracts the metadata (ID, box, due date, enabled, content hash,
+// scheduler hint; embedded in an html comment tag) from a line. See metadataRe for
+// the supported forms.
+func getMetadata(line string) (id, box, due string, enabled bool, hash, algo string, ef float64, reps, interval uint) {
+	matches := metadataRe.FindStringSubmatch(line)
 	if len(matches) >= 4 {
 		id = matches[1]
 		box = matches[2]
 		due = matches[3]
-		if len(matches) >= 5 && matches[4] != "" {
+		if matches[4] != "" {
 			enabled = matches[4] == "true"
 		} else {
 			// default to enabled for retrocompatibility
 			enabled = true
 		}
-		return id, box, due, enabled
+		hash = matches[5]
+		algo = AlgoLeitner
+		if matches[6] == "sm2" {
+			algo = AlgoSM2
+			ef, _ = strconv.ParseFloat(matches[7], 64)
+			repsVal, _ := strconv.Atoi(matches[8])
+			reps = uint(repsVal)
+			intervalVal, _ := strconv.Atoi(matches[9])
+			interval = uint(intervalVal)
+		}
+		return id, box, due, enabled, hash, algo, ef, reps, interval
 	}
-	return "", "", "", true
+	return "", "", "", true, "", AlgoLeitner, 0, 0, 0
 }
 
-// initializeMetadata initializes the metadata (ID, box, due date, enabled; embedded in html comment tag) for a new card.
-func initializeMetadata(line string) (updatedLine, id, box, due string, enabled bool) {
+// initializeMetadata initializes the metadata (ID, box, due date, enabled, scheduler
+// hint; embedded in html comment tag) for a new card. algo selects the scheduler the
+// card is initialized for (AlgoLeitner when empty); AlgoSM2 additionally seeds the
+// default ease factor, repetition count and interval.
+func initializeMetadata(line, algo string) (updatedLine, id, box, due string, enabled bool) {
 	id = gonanoid.MustGenerate("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", 4)
 	box = "0"
 	due = time.Now().Format("2006-01-02")
@@ -46,24 +72,34 @@ func initializeMetadata(line string) (updatedLine, id, box, due string, enabled
 	// Make sure there are no unrecognized html comment tags present in the line
 	updatedLine = regexp.MustCompile(`\s*<!--.*-->`).ReplaceAllString(line, "")
 	// Include the enabled flag for new metadata (retrocompatible parsers will ignore it)
-	updatedLine = fmt.Sprintf("%s <!--%s;%s;%s;%t-->", updatedLine, id, box, due, enabled)
+	if algo == AlgoSM2 {
+		updatedLine = fmt.Sprintf("%s <!--%s;%s;%s;%t;sm2;2.50;0;0-->", updatedLine, id, box, due, enabled)
+	} else {
+		updatedLine = fmt.Sprintf("%s <!--%s;%s;%s;%t-->", updatedLine, id, box, due, enabled)
+	}
 	return
 }
 
 // generateNewId generates a new id for a card and updates the line with the new id.
-// It preserves existing box/due/enabled when possible. If no metadata is present it will append new metadata.
+// It preserves existing box/due/enabled/sm2 state when possible. If no metadata is
+// present it will append new metadata.
 func generateNewId(line string) (updatedLine, id string) {
-	re := regexp.MustCompile(`<!--\s*(.{4});(\d);(\d{4}-\d{2}-\d{2})(?:;(true|false))?\s*-->`)
-	matches := re.FindStringSubmatch(line)
+	matches := metadataRe.FindStringSubmatch(line)
 	id = gonanoid.MustGenerate("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", 4)
 	if len(matches) >= 4 {
-		// matches[2] = box, matches[3] = due, matches[4] = enabled (if present)
+		// matches[2]=box, [3]=due, [4]=enabled, [5]=hash, [6]-[9]=sm2 state (each optional)
 		updated := fmt.Sprintf("<!--%s;%s;%s", id, matches[2], matches[3])
-		if len(matches) >= 5 && matches[4] != "" {
+		if matches[4] != "" {
 			updated = fmt.Sprintf("%s;%s", updated, matches[4])
 		}
+		if matches[5] != "" {
+			updated = fmt.Sprintf("%s;%s", updated, matches[5])
+		}
+		if matches[6] == "sm2" {
+			updated = fmt.Sprintf("%s;sm2;%s;%s;%s", updated, matches[7], matches[8], matches[9])
+		}
 		updated = updated + "-->"
-		updatedLine = re.ReplaceAllString(line, updated)
+		updatedLine = metadataRe.ReplaceAllString(line, updated)
 		return
 	}
 	// Fallback: append a fresh metadata block (shouldn't commonly happen because OpenFile initializes missing metadata)
@@ -86,9 +122,14 @@ func extractQuestion(line string) string {
 // getCardFromLine extracts the card data from a second-level (or third, etc.) markdown header.
 func getCardFromLine(line, category string) (card Card) {
 	card.Category = category
-	id, box, due, enabled := getMetadata(line)
+	id, box, due, enabled, hash, algo, ef, reps, interval := getMetadata(line)
 	card.Id = id
 	card.Enabled = enabled
+	card.Hash = hash
+	card.Algo = algo
+	card.EF = ef
+	card.Reps = reps
+	card.Interval = interval
 	boxUint, err := strconv.Atoi(box)
 	check(err)
 	card.Box = uint(boxUint)
@@ -121,9 +162,9 @@ func (s *Session) OpenFile(path string) error {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "#### ") {
-			id, _, _, _ := getMetadata(line)
+			id, _, _, _, _, _, _, _, _ := getMetadata(line)
 			if id == "" {
-				line, id, _, _, _ = initializeMetadata(line)
+				line, id, _, _, _ = initializeMetadata(line, s.Algo)
 			}
 			for ids[id] {
 				line, id = generateNewId(line)
@@ -184,17 +225,62 @@ func (s *Session) OpenFile(path string) error {
 		return errors.New("no flashcards found in file")
 	}
 
+	s.syncCardHashes()
+
 	return nil
 }
 
+// syncCardHashes recomputes each card's content hash and compares it against the
+// hash stored in its metadata. A card with no stored hash yet (e.g. freshly created,
+// or written before this feature existed) simply has its hash recorded. A mismatch
+// means the card's front/back were edited since the hash was last stored, and
+// s.OnEdit decides what happens to its schedule: OnEditReset starts the card fresh,
+// OnEditPrompt asks the user interactively, and OnEditKeep (the default) leaves the
+// schedule untouched.
+func (s *Session) syncCardHashes() {
+	for i := range s.File.Cards {
+		c := &s.File.Cards[i]
+		newHash := contentHash(c.Front, c.Back)
+		if c.Hash == newHash {
+			continue
+		}
+		if c.Hash != "" {
+			switch s.OnEdit {
+			case OnEditReset:
+				c.Box = 0
+				c.Due = today()
+			case OnEditPrompt:
+				fmt.Printf("Card %q changed since it was last reviewed.\n", c.Front)
+				fmt.Println("(1) keep current schedule  (2) reset schedule")
+				if ReadNumberInput(1, 2) == 2 {
+					c.Box = 0
+					c.Due = today()
+				}
+			}
+		}
+		c.Hash = newHash
+		s.updateCardInFile(c)
+	}
+}
+
 // updateCardInFile Updates the card's metadata in the file.
 func (s *Session) updateCardInFile(c *Card) {
 	data, err := os.ReadFile(s.File.Path)
 	check(err)
 	md := string(data)
-	// Match metadata with optional enabled flag and replace it with a normalized version that includes enabled.
-	re := regexp.MustCompile(fmt.Sprintf(`<!--\s*%s;\d;\d{4}-\d{2}-\d{2}(?:;(true|false))?\s*-->`, c.Id))
-	md = re.ReplaceAllString(md, fmt.Sprintf("<!--%s;%d;%s;%t-->", c.Id, c.Box, c.Due.Format("2006-01-02"), c.Enabled))
+	// Match metadata for this card, including any hash/sm2 fields, and replace it
+	// with a normalized version that includes enabled, the content hash (if any) and,
+	// for sm2 cards, the ease factor, repetition count and interval.
+	re := regexp.MustCompile(fmt.Sprintf(`<!--\s*%s;\d+;\d{4}-\d{2}-\d{2}(?:;(true|false))?(?:;[0-9a-f]{12})?(?:;sm2;[\d.]+;\d+;\d+)?\s*-->`, c.Id))
+	meta := fmt.Sprintf("<!--%s;%d;%s;%t", c.Id, c.Box, c.Due.Format("2006-01-02"), c.Enabled)
+	if c.Hash != "" {
+		meta += ";" + c.Hash
+	}
+	if c.Algo == AlgoSM2 {
+		meta += fmt.Sprintf(";sm2;%.2f;%d;%d", c.EF, c.Reps, c.Interval)
+	}
+	meta += "-->"
+	md = re.ReplaceAllString(md, meta)
 	err = os.WriteFile(s.File.Path, []byte(md), 0644)
 	check(err)
 }
@@ -210,8 +296,12 @@ func (s *Session) CheckCategory() error {
 	return errors.New("category not found")
 }
 
-// ChooseCategory Lets the user choose a category from the file's headings.
-func (s *Session) ChooseCategory() {
+// ChooseCategory Lets the user choose a category from the file's headings. A
+// category can be picked by typing its number, or by navigating with j/k or the
+// up/down arrows and confirming with Enter or Space, all dispatched through km.
+// ActionQuit backs out of selection without choosing a category; ChooseCategory
+// reports whether the user quit instead, leaving s.Category untouched.
+func (s *Session) ChooseCategory(km KeyMap) (quit bool) {
 	fmt.Println("Please select the category you want to study:")
 	var categories []string
 	for _, c := range s.File.Cards {
@@ -223,13 +313,226 @@ func (s *Session) ChooseCategory() {
 		fmt.Printf("(%d) %s\n", i+1, c)
 	}
 
+	selected := 0
 	fmt.Print("Your choice: ")
-	choice := ReadNumberInput(1, len(categories))
-	s.Category = categories[choice-1]
+	for {
+		key, err := ReadKey()
+		if err != nil {
+			continue
+		}
+		if key.Rune >= '1' && key.Rune <= '9' {
+			if idx := int(key.Rune - '1'); idx < len(categories) {
+				selected = idx
+				break
+			}
+			continue
+		}
+		action, ok := km.Dispatch(key)
+		if !ok {
+			continue
+		}
+		switch action {
+		case ActionUp:
+			selected = (selected - 1 + len(categories)) % len(categories)
+		case ActionDown:
+			selected = (selected + 1) % len(categories)
+		case ActionReveal:
+			goto chosen
+		case ActionQuit:
+			fmt.Println()
+			return true
+		default:
+			continue
+		}
+		fmt.Printf("\r%d: %s    ", selected+1, categories[selected])
+	}
+chosen:
+	fmt.Println()
+	s.Category = categories[selected]
+	return false
+}
+
+// GradeCard grades the card at cardIndex with the user's recall grade (0-5),
+// advancing its schedule through the appropriate Scheduler (see SchedulerFor),
+// pushing its prior schedule onto the undo stack, and persisting the result.
+func (s *Session) GradeCard(cardIndex int, grade int) {
+	c := &s.File.Cards[cardIndex]
+	s.undoStack = append(s.undoStack, gradeSnapshot{
+		cardIndex: cardIndex,
+		box:       c.Box,
+		due:       c.Due,
+		algo:      c.Algo,
+		ef:        c.EF,
+		reps:      c.Reps,
+		interval:  c.Interval,
+	})
+
+	c.Box, c.Due = SchedulerFor(c, s.defaultScheduler()).NextReview(c, grade)
+	s.updateCardInFile(c)
+}
+
+// UndoLastGrade reverts the most recent GradeCard call, restoring the card's prior
+// schedule and persisting it back to disk. It reports whether there was anything
+// to undo.
+func (s *Session) UndoLastGrade() bool {
+	if len(s.undoStack) == 0 {
+		return false
+	}
+	snap := s.undoStack[len(s.undoStack)-1]
+	s.undoStack = s.undoStack[:len(s.undoStack)-1]
+
+	c := &s.File.Cards[snap.cardIndex]
+	c.Box = snap.box
+	c.Due = snap.due
+	c.Algo = snap.algo
+	c.EF = snap.ef
+	c.Reps = snap.reps
+	c.Interval = snap.interval
+	s.updateCardInFile(c)
+	return true
+}
+
+// defaultScheduler resolves the session's default Scheduler from s.Algo.
+func (s *Session) defaultScheduler() Scheduler {
+	if s.Algo == AlgoSM2 {
+		return SM2Scheduler{}
+	}
+	return LeitnerScheduler{Intervals: s.File.BoxIntervals}
+}
+
+// ReviewCard presents a single card's front and blocks until ActionReveal shows its
+// back, matching the recall-then-check shape of spaced-repetition review. Before the
+// reveal, ActionUndo/ActionDisable/ActionEdit/ActionQuit are still dispatched through
+// km, but grade keys are not accepted yet. After the reveal, grade keys (0-5) call
+// GradeCard and return immediately; ActionUndo rolls back the previous grade via
+// UndoLastGrade; ActionDisable flips the card's Enabled flag; ActionEdit opens the
+// deck in $EDITOR and re-syncs it; ActionQuit is reported back to the caller to end
+// the review loop.
+func (s *Session) ReviewCard(cardIndex int, km KeyMap) (Action, error) {
+	c := &s.File.Cards[cardIndex]
+	fmt.Println(WrapLines(FormatMarkdown(c.Front), 0))
+
+	for {
+		key, err := ReadKey()
+		if err != nil {
+			return "", err
+		}
+		action, ok := km.Dispatch(key)
+		if !ok {
+			continue
+		}
+		switch action {
+		case ActionQuit:
+			return ActionQuit, nil
+		case ActionUndo:
+			s.UndoLastGrade()
+		case ActionDisable:
+			c.Enabled = !c.Enabled
+			s.updateCardInFile(c)
+		case ActionEdit:
+			if err := s.editCardInEditor(); err != nil {
+				return "", err
+			}
+			if cardIndex >= len(s.File.Cards) {
+				return "", errors.New("card no longer exists after edit")
+			}
+			// editCardInEditor re-syncs via OpenFile, which replaces s.File.Cards with a
+			// fresh slice; re-fetch c so later branches don't write through a stale
+			// pointer into the discarded backing array.
+			c = &s.File.Cards[cardIndex]
+		case ActionReveal:
+			goto revealed
+		}
+	}
+revealed:
+	fmt.Println("---")
+	fmt.Println(WrapLines(FormatMarkdown(c.Back), 0))
+
+	for {
+		key, err := ReadKey()
+		if err != nil {
+			return "", err
+		}
+		if key.Rune >= '0' && key.Rune <= '5' {
+			grade, _ := strconv.Atoi(string(key.Rune))
+			s.GradeCard(cardIndex, grade)
+			return Action(fmt.Sprintf("grade%d", grade)), nil
+		}
+		action, ok := km.Dispatch(key)
+		if !ok {
+			continue
+		}
+		switch action {
+		case ActionQuit:
+			return ActionQuit, nil
+		case ActionUndo:
+			s.UndoLastGrade()
+		case ActionDisable:
+			c.Enabled = !c.Enabled
+			s.updateCardInFile(c)
+		case ActionEdit:
+			if err := s.editCardInEditor(); err != nil {
+				return "", err
+			}
+			if cardIndex >= len(s.File.Cards) {
+				return "", errors.New("card no longer exists after edit")
+			}
+			c = &s.File.Cards[cardIndex]
+		}
+	}
+}
+
+// editCardInEditor opens the deck file in $EDITOR (falling back to "vi") so the
+// user can fix up the current card, then reopens the file to re-sync its cards and
+// content hashes.
+func (s *Session) editCardInEditor() error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, s.File.Path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return s.OpenFile(s.File.Path)
+}
+
+// stripIdKeepHash replaces a card's ID with a freshly generated one and resets its
+// box/due/enabled state, but preserves its content hash (if any) and its scheduler
+// (algo), so that re-opening the shared copy can later match the card's progress
+// back by content rather than by the ID it shipped with, without silently
+// downgrading an SM2 card back to the Leitner scheduler.
+func stripIdKeepHash(line string) string {
+	_, _, _, _, hash, algo, _, _, _ := getMetadata(line)
+	updatedLine, id, box, due, enabled := initializeMetadata(line, algo)
+	if hash == "" {
+		return updatedLine
+	}
+	// The hash goes right after the enabled flag, ahead of any sm2 fields (see metadataRe).
+	prefix := fmt.Sprintf("<!--%s;%s;%s;%t", id, box, due, enabled)
+	return strings.Replace(updatedLine, prefix, prefix+";"+hash, 1)
+}
+
+// NewDeckFromTemplate writes the embedded default deck template (see the assets
+// package) to path, which must not already exist yet, giving new users a starter
+// deck they can immediately open with OpenFile.
+func NewDeckFromTemplate(path string) error {
+	if path == "" {
+		return errors.New("no file specified")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return errors.New("file already exists")
+	}
+	tmpl, err := assets.DefaultDeckTemplate()
+	check(err)
+	return os.WriteFile(path, tmpl, 0644)
 }
 
 // CreateCopyToShare Creates a copy of the file in the current directory, with the suffix '.share.md'. It reads
-// each line, resets the metadata, and writes the line to the new file.
+// each line, strips IDs (while keeping content hashes, see stripIdKeepHash), and writes the line to the new file.
 func CreateCopyToShare(path string) error {
 	if path == "" {
 		return errors.New("no file specified")
@@ -250,7 +553,7 @@ func CreateCopyToShare(path string) error {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "#### ") {
-			line, _, _, _, _ = initializeMetadata(line)
+			line = stripIdKeepHash(line)
 		}
 		_, err := newF.WriteString(line + "\n")
 		check(err)
@@ -262,4 +565,4 @@ func CreateCopyToShare(path string) error {
 	err = newF.Close()
 	check(err)
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"errors"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/falleng0d/markdown-flashcards/internal/assets"
+)
+
+// htmlDoc is the data template.html is executed with.
+type htmlDoc struct {
+	Title   string
+	CSS     template.CSS
+	Content template.HTML
+}
+
+// ExportHTML renders path's deck as a single self-contained '<name>.html' file next
+// to it, grouped by category, using the embedded stylesheet (see the assets
+// package). It returns the path to the created file.
+func ExportHTML(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("no file specified")
+	}
+	absPath, err := filepath.Abs(path)
+	check(err)
+
+	var s Session
+	if err := s.OpenFile(absPath); err != nil {
+		return "", err
+	}
+
+	tmplSrc, err := assets.Stylesheet("template.html")
+	check(err)
+	css, err := assets.Stylesheet("style.css")
+	check(err)
+	tmpl, err := template.New("deck").Parse(string(tmplSrc))
+	check(err)
+
+	var categories []string
+	for _, c := range s.File.Cards {
+		if !slices.Contains(categories, c.Category) {
+			categories = append(categories, c.Category)
+		}
+	}
+
+	var b strings.Builder
+	for _, category := range categories {
+		b.WriteString(`<div class="category">`)
+		b.WriteString(html.EscapeString(category))
+		b.WriteString("</div>\n")
+		for _, c := range s.File.Cards {
+			if c.Category != category {
+				continue
+			}
+			b.WriteString(`<div class="card">`)
+			b.WriteString(`<div class="front">`)
+			b.WriteString(escapeHTMLLines(c.Front))
+			b.WriteString("</div>\n")
+			b.WriteString(`<div class="back">`)
+			b.WriteString(escapeHTMLLines(c.Back))
+			b.WriteString("</div>\n")
+			b.WriteString("</div>\n")
+		}
+	}
+
+	htmlPath := strings.TrimSuffix(absPath, ".md") + ".html"
+	out, err := os.Create(htmlPath)
+	check(err)
+	defer out.Close()
+
+	err = tmpl.Execute(out, htmlDoc{
+		Title:   filepath.Base(strings.TrimSuffix(absPath, ".md")),
+		CSS:     template.CSS(css),
+		Content: template.HTML(b.String()),
+	})
+	check(err)
+
+	return htmlPath, nil
+}
+
+// escapeHTMLLines HTML-escapes s and turns its line breaks into <br> tags.
+func escapeHTMLLines(s string) string {
+	return strings.ReplaceAll(html.EscapeString(strings.TrimSpace(s)), "\n", "<br>\n")
+}
@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// hashLen is the number of hex characters kept from the truncated SHA-256 content hash.
+const hashLen = 12
+
+// On-edit policies controlling what happens to a card's schedule when its stored
+// content hash no longer matches its current front+back (see Session.OnEdit).
+const (
+	OnEditKeep   = "keep"
+	OnEditReset  = "reset"
+	OnEditPrompt = "prompt"
+)
+
+// contentHash returns a truncated hex-encoded SHA-256 hash of a card's front+back,
+// used as a content-based identity independent of its (edit-safe) ID: two cards with
+// the same front+back hash to the same value even if their IDs differ.
+func contentHash(front, back string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(front) + "\x00" + strings.TrimSpace(back)))
+	return hex.EncodeToString(sum[:])[:hashLen]
+}
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// SpecialKey identifies a non-printable key such as an arrow key. KeyNone means the
+// key event carries an ordinary printable rune instead (see Key.Rune).
+type SpecialKey int
+
+const (
+	KeyNone SpecialKey = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyEnter
+	KeyEscape
+	KeySpace
+)
+
+// Key is a single parsed key event: either a printable rune (Special == KeyNone) or
+// a special key such as an arrow key.
+type Key struct {
+	Rune    rune
+	Special SpecialKey
+}
+
+// stdinKeys buffers raw bytes read from stdin across ReadKey calls.
+var stdinKeys = bufio.NewReader(os.Stdin)
+
+// ReadKey reads a single key press from stdin, switching the terminal into raw mode
+// for the duration of the read so the key is returned without waiting for Enter. It
+// recognizes CSI escape sequences (\033[A etc.) for the arrow keys in addition to
+// ordinary runes, Enter and Space. If the terminal can't be switched to raw mode,
+// it falls back to reading whatever is typed verbatim.
+func ReadKey() (Key, error) {
+	fd := int(os.Stdin.Fd())
+	if oldState, err := term.MakeRaw(fd); err == nil {
+		defer func() { _ = term.Restore(fd, oldState) }()
+	}
+
+	r, _, err := stdinKeys.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return Key{Special: KeyEnter}, nil
+	case ' ':
+		return Key{Special: KeySpace}, nil
+	case 0x1b: // ESC, possibly the start of a CSI sequence
+		r2, _, err := stdinKeys.ReadRune()
+		if err != nil || r2 != '[' {
+			return Key{Special: KeyEscape}, nil
+		}
+		r3, _, err := stdinKeys.ReadRune()
+		if err != nil {
+			return Key{Special: KeyEscape}, nil
+		}
+		switch r3 {
+		case 'A':
+			return Key{Special: KeyUp}, nil
+		case 'B':
+			return Key{Special: KeyDown}, nil
+		case 'C':
+			return Key{Special: KeyRight}, nil
+		case 'D':
+			return Key{Special: KeyLeft}, nil
+		default:
+			return Key{Special: KeyEscape}, nil
+		}
+	default:
+		return Key{Rune: r}, nil
+	}
+}
+
+// Action identifies a user-facing command that a key press can trigger during
+// category selection or card review.
+type Action string
+
+const (
+	ActionQuit    Action = "quit"
+	ActionEdit    Action = "edit"
+	ActionDisable Action = "disable"
+	ActionUndo    Action = "undo"
+	ActionUp      Action = "up"
+	ActionDown    Action = "down"
+	ActionReveal  Action = "reveal"
+)
+
+// KeyMap maps a parsed Key to the Action it triggers.
+type KeyMap map[Key]Action
+
+// Dispatch returns the Action bound to k, and whether a binding was found.
+func (km KeyMap) Dispatch(k Key) (Action, bool) {
+	a, ok := km[k]
+	return a, ok
+}
+
+// DefaultKeyMap returns the built-in key bindings: q to quit, e to edit the current
+// card in $EDITOR, d to disable it, u to undo the last grade, j/k or the down/up
+// arrows to move the selection in ChooseCategory, and Enter/Space to reveal a card's
+// back or confirm a selection. Recall grades (0-5) are read directly as digits by
+// callers rather than routed through the KeyMap, matching the SM-2 scale (see
+// SM2Scheduler).
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		{Rune: 'q'}:         ActionQuit,
+		{Rune: 'e'}:         ActionEdit,
+		{Rune: 'd'}:         ActionDisable,
+		{Rune: 'u'}:         ActionUndo,
+		{Rune: 'j'}:         ActionDown,
+		{Rune: 'k'}:         ActionUp,
+		{Special: KeyDown}:  ActionDown,
+		{Special: KeyUp}:    ActionUp,
+		{Special: KeySpace}: ActionReveal,
+		{Special: KeyEnter}: ActionReveal,
+	}
+}
+
+// keyConfigLineRe matches a single `action = "key"` line in a keys.toml file.
+var keyConfigLineRe = regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)\s*=\s*"([^"]*)"\s*$`)
+
+// LoadKeyMapConfig reads user key-binding overrides from path, a minimal
+// `action = "key"` per line format (e.g. `quit = "Q"`, `down = "down"` for a
+// special key), layered on top of DefaultKeyMap. A missing file is not an error —
+// it just means no overrides are applied.
+func LoadKeyMapConfig(path string) (KeyMap, error) {
+	km := DefaultKeyMap()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return km, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := keyConfigLineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, ok := parseKeyName(m[2])
+		if !ok {
+			continue
+		}
+		km[key] = Action(m[1])
+	}
+	return km, nil
+}
+
+// parseKeyName parses a single key name from a config file: either a one-rune
+// string or the name of a special key (up, down, left, right, enter, escape, space).
+func parseKeyName(s string) (Key, bool) {
+	switch strings.ToLower(s) {
+	case "up":
+		return Key{Special: KeyUp}, true
+	case "down":
+		return Key{Special: KeyDown}, true
+	case "left":
+		return Key{Special: KeyLeft}, true
+	case "right":
+		return Key{Special: KeyRight}, true
+	case "enter":
+		return Key{Special: KeyEnter}, true
+	case "escape":
+		return Key{Special: KeyEscape}, true
+	case "space":
+		return Key{Special: KeySpace}, true
+	}
+	r := []rune(s)
+	if len(r) != 1 {
+		return Key{}, false
+	}
+	return Key{Rune: r[0]}, true
+}
+
+// DefaultKeyMapConfigPath returns the default location for user key-binding
+// overrides: ~/.config/mdflash/keys.toml.
+func DefaultKeyMapConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "mdflash", "keys.toml"), nil
+}
@@ -0,0 +1,385 @@
+package internal
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Node is one element of a parsed markdown document, either block-level
+// (HeadingNode, ParagraphNode, ListItemNode, CodeBlockNode, BlockquoteNode) or
+// inline-level (TextNode, BoldNode, ItalicNode, CodeNode, LinkNode).
+type Node interface{ isNode() }
+
+// TextNode is a run of plain text.
+type TextNode struct{ Text string }
+
+// BoldNode wraps **text** or __text__; Children may themselves contain Italic/Code/Link runs.
+type BoldNode struct{ Children []Node }
+
+// ItalicNode wraps *text* or _text_; Children may themselves contain Bold/Code/Link runs.
+type ItalicNode struct{ Children []Node }
+
+// CodeNode is an inline code span delimited by backticks.
+type CodeNode struct{ Text string }
+
+// LinkNode is a [label](url) reference.
+type LinkNode struct{ Label, URL string }
+
+// HeadingNode is an ATX-style `#`..`######` heading.
+type HeadingNode struct {
+	Level    int
+	Children []Node
+}
+
+// ParagraphNode is a run of one or more non-blank lines with no other block syntax.
+type ParagraphNode struct{ Children []Node }
+
+// ListItemNode is a single `-`/`+`/`*`/`N.` prefixed line. Number is the parsed
+// ordinal (e.g. 3 for "3.") and is only meaningful when Ordered is true.
+type ListItemNode struct {
+	Ordered  bool
+	Number   int
+	Children []Node
+}
+
+// CodeBlockNode is the content between a pair of fenced (```) lines.
+type CodeBlockNode struct{ Lang, Text string }
+
+// BlockquoteNode is a single `>`-prefixed line.
+type BlockquoteNode struct{ Children []Node }
+
+func (TextNode) isNode()       {}
+func (BoldNode) isNode()       {}
+func (ItalicNode) isNode()     {}
+func (CodeNode) isNode()       {}
+func (LinkNode) isNode()       {}
+func (HeadingNode) isNode()    {}
+func (ParagraphNode) isNode()  {}
+func (ListItemNode) isNode()   {}
+func (CodeBlockNode) isNode()  {}
+func (BlockquoteNode) isNode() {}
+
+var (
+	headingRe    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listItemRe   = regexp.MustCompile(`^\s*([-+*]|\d+\.)\s+(.*)$`)
+	blockquoteRe = regexp.MustCompile(`^>\s?(.*)$`)
+	fenceRe      = regexp.MustCompile("^```\\s*([a-zA-Z0-9_+-]*)\\s*$")
+)
+
+// ParseMarkdown parses s into a sequence of block-level nodes, each carrying its own
+// inline-level children (see Node).
+func ParseMarkdown(s string) []Node {
+	lines := strings.Split(s, "\n")
+	var nodes []Node
+	var para []string
+	flushPara := func() {
+		if len(para) == 0 {
+			return
+		}
+		nodes = append(nodes, ParagraphNode{Children: parseInline(strings.Join(para, " "))})
+		para = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case fenceRe.MatchString(line):
+			flushPara()
+			lang := fenceRe.FindStringSubmatch(line)[1]
+			var code []string
+			i++
+			for i < len(lines) && !fenceRe.MatchString(lines[i]) {
+				code = append(code, lines[i])
+				i++
+			}
+			nodes = append(nodes, CodeBlockNode{Lang: lang, Text: strings.Join(code, "\n")})
+		case headingRe.MatchString(line):
+			flushPara()
+			m := headingRe.FindStringSubmatch(line)
+			nodes = append(nodes, HeadingNode{Level: len(m[1]), Children: parseInline(m[2])})
+		case blockquoteRe.MatchString(line):
+			flushPara()
+			m := blockquoteRe.FindStringSubmatch(line)
+			nodes = append(nodes, BlockquoteNode{Children: parseInline(m[1])})
+		case listItemRe.MatchString(line):
+			flushPara()
+			m := listItemRe.FindStringSubmatch(line)
+			ordered := m[1] != "-" && m[1] != "+" && m[1] != "*"
+			number, _ := strconv.Atoi(strings.TrimSuffix(m[1], "."))
+			nodes = append(nodes, ListItemNode{Ordered: ordered, Number: number, Children: parseInline(m[2])})
+		case strings.TrimSpace(line) == "":
+			flushPara()
+		default:
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	flushPara()
+	return nodes
+}
+
+// parseInline tokenizes a run of text into Text/Bold/Italic/Code/Link nodes,
+// honouring backslash-escapes and allowing Bold/Italic to nest (e.g. `**_x_**`).
+func parseInline(s string) []Node {
+	runes := []rune(s)
+	var nodes []Node
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, TextNode{Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	i := 0
+	for i < len(runes) {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			text.WriteRune(runes[i+1])
+			i += 2
+		case runes[i] == '`':
+			if j := indexRune(runes, i+1, '`'); j != -1 {
+				flushText()
+				nodes = append(nodes, CodeNode{Text: string(runes[i+1 : j])})
+				i = j + 1
+			} else {
+				text.WriteRune(runes[i])
+				i++
+			}
+		case runes[i] == '[':
+			if label, url, next, ok := parseLink(runes, i); ok {
+				flushText()
+				nodes = append(nodes, LinkNode{Label: label, URL: url})
+				i = next
+			} else {
+				text.WriteRune(runes[i])
+				i++
+			}
+		case hasDelimAt(runes, i, "**"), hasDelimAt(runes, i, "__"):
+			marker := string(runes[i : i+2])
+			if inner, next, ok := findClosingDelim(runes, i+2, marker); ok {
+				flushText()
+				nodes = append(nodes, BoldNode{Children: parseInline(inner)})
+				i = next
+			} else {
+				text.WriteRune(runes[i])
+				i++
+			}
+		case runes[i] == '*' || runes[i] == '_':
+			marker := string(runes[i])
+			if inner, next, ok := findClosingDelim(runes, i+1, marker); ok {
+				flushText()
+				nodes = append(nodes, ItalicNode{Children: parseInline(inner)})
+				i = next
+			} else {
+				text.WriteRune(runes[i])
+				i++
+			}
+		default:
+			text.WriteRune(runes[i])
+			i++
+		}
+	}
+	flushText()
+	return nodes
+}
+
+// hasDelimAt reports whether marker occurs at runes[i:].
+func hasDelimAt(runes []rune, i int, marker string) bool {
+	m := []rune(marker)
+	if i+len(m) > len(runes) {
+		return false
+	}
+	for k, mr := range m {
+		if runes[i+k] != mr {
+			return false
+		}
+	}
+	return true
+}
+
+// findClosingDelim scans runes from start for the next unescaped occurrence of
+// marker, returning the text in between and the index just past the closing marker.
+func findClosingDelim(runes []rune, start int, marker string) (inner string, next int, ok bool) {
+	for i := start; i+len([]rune(marker)) <= len(runes); i++ {
+		if runes[i] == '\\' {
+			i++
+			continue
+		}
+		if hasDelimAt(runes, i, marker) {
+			return string(runes[start:i]), i + len([]rune(marker)), true
+		}
+	}
+	return "", 0, false
+}
+
+// indexRune returns the index of the first occurrence of r in runes at or after
+// start, or -1 if not found.
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLink parses a `[label](url)` reference starting at runes[i] (which must be '[').
+func parseLink(runes []rune, i int) (label, url string, next int, ok bool) {
+	depth := 1
+	j := i + 1
+	labelStart := j
+	for j < len(runes) && depth > 0 {
+		switch runes[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				goto closed
+			}
+		}
+		j++
+	}
+	return "", "", 0, false
+closed:
+	label = string(runes[labelStart:j])
+	j++ // skip ']'
+	if j >= len(runes) || runes[j] != '(' {
+		return "", "", 0, false
+	}
+	j++
+	urlStart := j
+	for j < len(runes) && runes[j] != ')' {
+		j++
+	}
+	if j >= len(runes) {
+		return "", "", 0, false
+	}
+	return label, string(runes[urlStart:j]), j + 1, true
+}
+
+// Renderer turns a parsed markdown AST into a display string. ANSIRenderer is the
+// only implementation today; an HTML or terminal-image backend could implement the
+// same interface without touching ParseMarkdown.
+type Renderer interface {
+	Render(nodes []Node) string
+}
+
+// ANSIRenderer renders a markdown AST for a terminal: bold/italic text attributes,
+// reverse video for inline code, a dim background for fenced code blocks, a left
+// gutter for blockquotes, and hanging indents for list items.
+type ANSIRenderer struct{}
+
+// Render implements Renderer.
+func (ANSIRenderer) Render(nodes []Node) string {
+	var b strings.Builder
+	for i, n := range nodes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		renderBlock(&b, n)
+	}
+	return b.String()
+}
+
+func renderBlock(b *strings.Builder, n Node) {
+	switch v := n.(type) {
+	case HeadingNode:
+		b.WriteString("\033[1m")
+		b.WriteString(renderInline(v.Children))
+		b.WriteString("\033[0m")
+	case ParagraphNode:
+		b.WriteString(renderInline(v.Children))
+	case ListItemNode:
+		if v.Ordered {
+			b.WriteString(strconv.Itoa(v.Number))
+			b.WriteString(". ")
+		} else {
+			b.WriteString("- ")
+		}
+		b.WriteString(renderInline(v.Children))
+	case BlockquoteNode:
+		b.WriteString("│ ")
+		b.WriteString(renderInline(v.Children))
+	case CodeBlockNode:
+		b.WriteString("\033[48;5;236m")
+		b.WriteString(v.Text)
+		b.WriteString("\033[0m")
+	}
+}
+
+func renderInline(nodes []Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch v := n.(type) {
+		case TextNode:
+			b.WriteString(v.Text)
+		case BoldNode:
+			b.WriteString("\033[1m")
+			b.WriteString(renderInline(v.Children))
+			b.WriteString("\033[0m")
+		case ItalicNode:
+			b.WriteString("\033[3m")
+			b.WriteString(renderInline(v.Children))
+			b.WriteString("\033[0m")
+		case CodeNode:
+			b.WriteString("\033[7m")
+			b.WriteString(v.Text)
+			b.WriteString("\033[0m")
+		case LinkNode:
+			b.WriteString(v.Label)
+			b.WriteString(" (")
+			b.WriteString(v.URL)
+			b.WriteString(")")
+		}
+	}
+	return b.String()
+}
+
+// FormatMarkdown converts a subset of Markdown (bold, italics, inline code, links,
+// fenced code blocks, blockquotes, headings, ordered/unordered lists) to
+// console-friendly output via ANSIRenderer. Unlike the previous regex-based pass,
+// nested and escaped markup (e.g. `**_x_**`, “ `*literal*` “, `\*literal\*`) is
+// parsed correctly instead of mangled.
+func FormatMarkdown(s string) string {
+	return ANSIRenderer{}.Render(ParseMarkdown(s))
+}
+
+// ansiRe matches a single ANSI SGR escape sequence, e.g. "\033[1m".
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripANSI removes all ANSI escape sequences from s.
+func stripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// visibleIndexMap strips ANSI escape sequences from s and returns the visible text
+// alongside, for each rune of that visible text, the byte offset in s at which that
+// rune (and any escape codes immediately preceding it) begins. This lets callers
+// measure and break on s's *visible* width (runes, not bytes, ignoring escape
+// codes) while still slicing the original, styled string at the right byte offset.
+func visibleIndexMap(s string) (visible string, offsets []int) {
+	var b strings.Builder
+	idx := 0
+	for idx < len(s) {
+		start := idx
+		for idx < len(s) {
+			loc := ansiRe.FindStringIndex(s[idx:])
+			if loc == nil || loc[0] != 0 {
+				break
+			}
+			idx += loc[1]
+		}
+		if idx >= len(s) {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(s[idx:])
+		offsets = append(offsets, start)
+		b.WriteRune(r)
+		idx += size
+	}
+	offsets = append(offsets, len(s))
+	return b.String(), offsets
+}
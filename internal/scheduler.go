@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm identifiers stored in a card's metadata comment and used to select a
+// Scheduler implementation.
+const (
+	AlgoLeitner = "leitner"
+	AlgoSM2     = "sm2"
+)
+
+// Scheduler determines how a card's box and due date evolve after a review. Each
+// implementation is free to use whatever fields on Card it needs to track state
+// between reviews (LeitnerScheduler uses Box, SM2Scheduler also uses EF/Reps/Interval).
+type Scheduler interface {
+	// NextReview computes the next box and due date for c after the user grades
+	// their recall as grade (0-5, where 0 is a complete blackout and 5 is a perfect
+	// response). Implementations may also mutate scheduler-specific fields on c.
+	NextReview(c *Card, grade int) (box uint, due time.Time)
+}
+
+// LeitnerScheduler implements the classic Leitner box system: a passing grade
+// advances the card to the next box, a failing grade resets it to box 0. The due
+// date is today plus the interval configured for the resulting box.
+type LeitnerScheduler struct {
+	Intervals []int
+}
+
+// NextReview implements Scheduler.
+func (s LeitnerScheduler) NextReview(c *Card, grade int) (box uint, due time.Time) {
+	intervals := s.Intervals
+	if len(intervals) == 0 {
+		intervals = boxIntervals
+	}
+
+	if grade < 3 {
+		box = 0
+	} else {
+		box = c.Box + 1
+		if int(box) >= len(intervals) {
+			box = uint(len(intervals) - 1)
+		}
+	}
+
+	days := 0
+	if int(box) < len(intervals) {
+		days = intervals[box]
+	}
+	due = today().AddDate(0, 0, days)
+	return box, due
+}
+
+// SM2Scheduler implements the SM-2 spaced-repetition algorithm popularized by
+// SuperMemo. It keeps per-card state in Card.EF (ease factor), Card.Reps (the
+// repetition count n) and Card.Interval (the interval I, in days).
+type SM2Scheduler struct{}
+
+// NextReview implements Scheduler. grade is clamped to [0, 5].
+func (SM2Scheduler) NextReview(c *Card, grade int) (box uint, due time.Time) {
+	if grade < 0 {
+		grade = 0
+	} else if grade > 5 {
+		grade = 5
+	}
+
+	ef := c.EF
+	if ef == 0 {
+		ef = 2.5
+	}
+	n := c.Reps
+	interval := c.Interval
+
+	if grade < 3 {
+		n = 0
+		interval = 1
+	} else {
+		switch n {
+		case 0:
+			interval = 1
+		case 1:
+			interval = 6
+		default:
+			interval = uint(math.Round(float64(interval) * ef))
+		}
+		n++
+	}
+
+	ef = ef + 0.1 - float64(5-grade)*(0.08+float64(5-grade)*0.02)
+	if ef < 1.3 {
+		ef = 1.3
+	}
+
+	c.Algo = AlgoSM2
+	c.EF = ef
+	c.Reps = n
+	c.Interval = interval
+
+	due = today().AddDate(0, 0, int(interval))
+	// SM2Scheduler has no box concept of its own (the repetition count n, already
+	// persisted on c.Reps, plays that role); leave c.Box untouched rather than
+	// overloading it with n, which would grow unbounded across reviews.
+	return c.Box, due
+}
+
+// SchedulerFor resolves the Scheduler that should review c, preferring the algorithm
+// hinted in the card's own metadata (the `<!--algo-->` field, carried on Card.Algo)
+// and falling back to def, the session's default scheduler (selectable via CLI flag).
+func SchedulerFor(c *Card, def Scheduler) Scheduler {
+	switch c.Algo {
+	case AlgoSM2:
+		return SM2Scheduler{}
+	case AlgoLeitner:
+		return LeitnerScheduler{Intervals: boxIntervals}
+	default:
+		return def
+	}
+}
+
+// today returns today's date truncated to midnight UTC, matching the precision due
+// dates are persisted at.
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// imageLinkRe matches a markdown image reference: ![alt](path)
+var imageLinkRe = regexp.MustCompile(`!\[[^\]]*\]\(([^)]+)\)`)
+
+// ShareManifest describes a shared deck bundle; it is written as manifest.json
+// inside the zip produced by CreateShareBundle.
+type ShareManifest struct {
+	CardCount       int      `json:"cardCount"`
+	Categories      []string `json:"categories"`
+	OriginatingHash string   `json:"originatingHash"`
+}
+
+// CreateShareBundle creates a self-contained '<name>.share.zip' next to path,
+// containing the stripped markdown (IDs reset but content hashes kept, see
+// stripIdKeepHash), any local image files referenced via markdown image links
+// (![alt](path)), and a manifest.json with deck metadata. It returns the path to
+// the created bundle.
+func CreateShareBundle(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("no file specified")
+	}
+	absPath, err := filepath.Abs(path)
+	check(err)
+	dir := filepath.Dir(absPath)
+
+	var s Session
+	if err := s.OpenFile(absPath); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(absPath)
+	check(err)
+
+	bundlePath := strings.TrimSuffix(absPath, ".md") + ".share.zip"
+	zf, err := os.Create(bundlePath)
+	check(err)
+	defer zf.Close()
+	zw := zip.NewWriter(zf)
+
+	mdName := filepath.Base(strings.TrimSuffix(absPath, ".md") + ".share.md")
+	mdWriter, err := zw.Create(mdName)
+	check(err)
+
+	images := make(map[string]bool)
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "#### ") {
+			line = stripIdKeepHash(line)
+			lines[i] = line
+		}
+		for _, m := range imageLinkRe.FindAllStringSubmatch(line, -1) {
+			images[m[1]] = true
+		}
+	}
+	_, err = mdWriter.Write([]byte(strings.Join(lines, "\n")))
+	check(err)
+
+	for imgPath := range images {
+		if isRemoteRef(imgPath) {
+			continue
+		}
+		absImg := imgPath
+		if !filepath.IsAbs(absImg) {
+			absImg = filepath.Join(dir, imgPath)
+		}
+		imgData, err := os.ReadFile(absImg)
+		if err != nil {
+			// Skip attachments that can't be found rather than failing the whole bundle.
+			continue
+		}
+		iw, err := zw.Create(imgPath)
+		check(err)
+		_, err = iw.Write(imgData)
+		check(err)
+	}
+
+	var categories []string
+	for _, c := range s.File.Cards {
+		if !slices.Contains(categories, c.Category) {
+			categories = append(categories, c.Category)
+		}
+	}
+	manifestBytes, err := json.MarshalIndent(ShareManifest{
+		CardCount:       len(s.File.Cards),
+		Categories:      categories,
+		OriginatingHash: deckHash(s.File.Cards),
+	}, "", "  ")
+	check(err)
+	mw, err := zw.Create("manifest.json")
+	check(err)
+	_, err = mw.Write(manifestBytes)
+	check(err)
+
+	check(zw.Close())
+	return bundlePath, nil
+}
+
+// ImportShare unpacks a '.share.zip' bundle created by CreateShareBundle into a
+// working directory next to it (named after the bundle, without the .share.zip
+// suffix) and opens the extracted markdown file into a new Session. If that working
+// directory already holds a deck from a previous import of the same bundle, cards
+// whose content hash matches one from that previous deck have their progress
+// (box, due date, enabled, scheduler state) carried over rather than reset, since
+// extraction overwrites that deck with the bundle's own metadata, whose IDs and
+// schedules were reset when the bundle was created (see stripIdKeepHash).
+func ImportShare(path string) (*Session, error) {
+	if path == "" {
+		return nil, errors.New("no file specified")
+	}
+	absPath, err := filepath.Abs(path)
+	check(err)
+
+	zr, err := zip.OpenReader(absPath)
+	if err != nil {
+		return nil, errors.New("file not found")
+	}
+	defer zr.Close()
+
+	workDir := strings.TrimSuffix(absPath, ".share.zip")
+	check(os.MkdirAll(workDir, 0755))
+	previousCards := previouslyImportedCards(workDir)
+
+	var mdPath string
+	for _, zfile := range zr.File {
+		destPath, err := safeJoin(workDir, zfile.Name)
+		if err != nil {
+			return nil, err
+		}
+		if zfile.FileInfo().IsDir() {
+			check(os.MkdirAll(destPath, 0755))
+			continue
+		}
+		check(os.MkdirAll(filepath.Dir(destPath), 0755))
+		if err := extractZipFile(zfile, destPath); err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(zfile.Name, ".md") {
+			mdPath = destPath
+		}
+	}
+	if mdPath == "" {
+		return nil, errors.New("share bundle does not contain a markdown deck")
+	}
+
+	s := &Session{}
+	if err := s.OpenFile(mdPath); err != nil {
+		return nil, err
+	}
+	s.mergeCardProgress(previousCards)
+	return s, nil
+}
+
+// previouslyImportedCards looks for a markdown deck already present in workDir (left
+// behind by an earlier ImportShare call against the same bundle path) and returns its
+// cards, or nil if there is none yet.
+func previouslyImportedCards(workDir string) []Card {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+			continue
+		}
+		var prev Session
+		if err := prev.OpenFile(filepath.Join(workDir, e.Name())); err == nil {
+			return prev.File.Cards
+		}
+	}
+	return nil
+}
+
+// mergeCardProgress carries Box, Due, Enabled and scheduler state over from previous
+// onto s's freshly parsed cards wherever their content hash matches, so re-importing
+// an updated share bundle doesn't reset progress (or silently re-enable a card the
+// recipient had disabled) on cards whose content didn't change.
+func (s *Session) mergeCardProgress(previous []Card) {
+	byHash := make(map[string]Card, len(previous))
+	for _, c := range previous {
+		if c.Hash != "" {
+			byHash[c.Hash] = c
+		}
+	}
+	for i := range s.File.Cards {
+		c := &s.File.Cards[i]
+		prev, ok := byHash[c.Hash]
+		if c.Hash == "" || !ok {
+			continue
+		}
+		c.Box = prev.Box
+		c.Due = prev.Due
+		c.Enabled = prev.Enabled
+		c.Algo = prev.Algo
+		c.EF = prev.EF
+		c.Reps = prev.Reps
+		c.Interval = prev.Interval
+		s.updateCardInFile(c)
+	}
+}
+
+// safeJoin joins name (a zip entry path, untrusted since bundles are exchanged
+// between users) onto dir and reports an error if the result would escape dir via
+// ".." segments (the "Zip Slip" vulnerability). An absolute name is joined under dir
+// like any other name.
+func safeJoin(dir, name string) (string, error) {
+	joined := filepath.Join(dir, name)
+	if rel, err := filepath.Rel(dir, joined); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("share bundle entry %q escapes the extraction directory", name)
+	}
+	return joined, nil
+}
+
+// extractZipFile copies a single entry of an open zip archive to destPath.
+func extractZipFile(zfile *zip.File, destPath string) error {
+	rc, err := zfile.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, rc)
+	return err
+}
+
+// isRemoteRef reports whether a markdown image reference points at a remote
+// resource rather than a local file, and so shouldn't be bundled.
+func isRemoteRef(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "data:")
+}
+
+// deckHash returns a short, stable hash identifying a deck's overall content,
+// derived from the sorted per-card content hashes (see contentHash).
+func deckHash(cards []Card) string {
+	hashes := make([]string, len(cards))
+	for i, c := range cards {
+		hashes[i] = c.Hash
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, ",")))
+	return hex.EncodeToString(sum[:])[:hashLen]
+}
@@ -0,0 +1,67 @@
+package internal
+
+import "time"
+
+// Card represents a single flashcard parsed from a markdown file.
+type Card struct {
+	Id       string
+	Category string
+	Front    string
+	Back     string
+	Box      uint
+	Due      time.Time
+	Enabled  bool
+
+	// Algo identifies which Scheduler owns this card's review state (AlgoLeitner by
+	// default). EF, Reps and Interval are only meaningful when Algo is AlgoSM2.
+	Algo     string
+	EF       float64
+	Reps     uint
+	Interval uint
+
+	// Hash is the content hash (see contentHash) stored in the card's metadata the
+	// last time its schedule was synced, used to detect edits to Front/Back.
+	Hash string
+}
+
+// File represents an opened flashcard markdown file together with the cards parsed from it.
+type File struct {
+	Path         string
+	BoxIntervals []int
+	Cards        []Card
+}
+
+// Session holds the state for an interactive study session.
+type Session struct {
+	File     File
+	Category string
+
+	// Algo is the default scheduler new cards are initialized for (AlgoLeitner when
+	// empty), selectable via the --algo CLI flag. Individual cards may override it
+	// with their own `<!--algo-->` hint, resolved through SchedulerFor.
+	Algo string
+
+	// OnEdit selects what happens to a card's schedule when its content hash has
+	// drifted (OnEditKeep, OnEditReset or OnEditPrompt), selectable via the
+	// --on-edit CLI flag. Defaults to OnEditKeep when empty.
+	OnEdit string
+
+	// undoStack holds the schedule a card had before its most recent GradeCard
+	// call, most recent last, so UndoLastGrade can roll it back.
+	undoStack []gradeSnapshot
+}
+
+// gradeSnapshot captures a card's schedule-relevant fields before a grade is
+// applied, so GradeCard's effect can be rolled back by UndoLastGrade.
+type gradeSnapshot struct {
+	cardIndex int
+	box       uint
+	due       time.Time
+	algo      string
+	ef        float64
+	reps      uint
+	interval  uint
+}
+
+// boxIntervals defines, for each Leitner box, the number of days until the card is due again.
+var boxIntervals = []int{1, 2, 4, 8, 16, 32}
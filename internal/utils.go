@@ -44,8 +44,10 @@ func PrintJSON[T any](v T) {
 }
 
 // ReadNumberInput reads a number from standard input. The number must be within i and j.
-// It now accepts a single digit immediately when the user presses the key (no Enter required).
+// It accepts a single digit immediately when the user presses the key (no Enter required).
 // If the terminal cannot be switched to raw mode it falls back to the previous behavior.
+// Input is read through stdinKeys (see keys.go) rather than directly off os.Stdin, so it
+// can't lose bytes ReadKey has already pulled into that reader's internal buffer.
 func ReadNumberInput(i, j int) int {
 	res := i - 1
 
@@ -54,7 +56,7 @@ func ReadNumberInput(i, j int) int {
 	oldState, err := term.MakeRaw(fd)
 	if err != nil {
 		// Fallback to line-based input if raw mode isn't available.
-		scanner := bufio.NewScanner(os.Stdin)
+		scanner := bufio.NewScanner(stdinKeys)
 		for res < i || res > j {
 			scanner.Scan()
 			in := scanner.Text()
@@ -70,15 +72,13 @@ func ReadNumberInput(i, j int) int {
 	// Ensure terminal state is restored.
 	defer func() { _ = term.Restore(fd, oldState) }()
 
-	buf := make([]byte, 1)
 	for res < i || res > j {
 		fmt.Print("Please enter a number: ")
 		// Read a single byte (key press).
-		n, err := os.Stdin.Read(buf)
-		if err != nil || n == 0 {
+		b, err := stdinKeys.ReadByte()
+		if err != nil {
 			continue
 		}
-		b := buf[0]
 		// Accept only ASCII digits 0-9.
 		if b >= '0' && b <= '9' {
 			nr := int(b - '0')
@@ -97,9 +97,10 @@ func ReadNumberInput(i, j int) int {
 	return res
 }
 
-// ReadEnterInput Blocks until the user enters a newline.
+// ReadEnterInput Blocks until the user enters a newline. Reads through stdinKeys (see
+// keys.go), the same buffered reader ReadKey uses, for the same reason ReadNumberInput does.
 func ReadEnterInput() {
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := bufio.NewScanner(stdinKeys)
 	scanner.Scan()
 }
 
@@ -139,6 +140,11 @@ func FindClosestDate(cards []Card) (time.Time, error) {
 // requested line length. Lines that start with an indent will be indented by the given indent plus, if the line is
 // a list item, the length of the list item prefix.
 //
+// Width is measured in visible terminal columns: ANSI escape sequences (as produced
+// by FormatMarkdown) are zero-width and runes, not bytes, are counted, so lines
+// carrying multi-byte UTF-8 text or ANSI styling wrap at the same point a plain
+// ASCII line would.
+//
 // If the lineLength is 0, it will wrap the text depending on the terminal width.
 func WrapLines(s string, lineLength uint) string {
 	if lineLength == 0 {
@@ -158,54 +164,25 @@ func WrapLines(s string, lineLength uint) string {
 			result += "\n"
 		}
 
-		linePrefix := indentRegex.FindString(line)
-		lineIndent := len(linePrefix)
+		linePrefix := indentRegex.FindString(stripANSI(line))
+		lineIndent := len([]rune(linePrefix))
 		for len(line) > 0 {
-			if uint(len(line)) <= lineLength {
+			visible, offsets := visibleIndexMap(line)
+			if uint(len([]rune(visible))) <= lineLength {
 				result += line + "\n"
 				break
 			} else {
 				idx := len(line)
-				idxs := lineBreakRegex.FindStringIndex(line)
+				idxs := lineBreakRegex.FindStringIndex(visible)
 				if idxs != nil {
-					idx = idxs[1]
+					idx = offsets[len([]rune(visible[:idxs[1]]))]
 				}
 				result += line[:idx] + "\n"
 				remainder := strings.TrimSpace(line[idx:])
-				remainderLen := len([]rune(remainder))
-				paddingFmt := fmt.Sprintf("%%%ds", lineIndent+remainderLen)
-				line = fmt.Sprintf(paddingFmt, remainder)
+				line = strings.Repeat(" ", lineIndent) + remainder
 			}
 		}
 	}
 
 	return result
 }
-
-// FormatMarkdown converts a small subset of Markdown (bold, italics, links) to console-friendly output.
-// - Bold: **text** or __text__ -> ANSI bold
-// - Italic: *text* or _text_ -> ANSI italic
-// - Links: [label](url) -> "label (url)"
-//
-// This implementation is intentionally small and does not attempt to fully parse Markdown.
-// It performs simple regex-based replacements which are sufficient for basic formatting.
-func FormatMarkdown(s string) string {
-	// Convert links first so we don't accidentally format parts of the URL as bold/italic.
-	linkRe := regexp.MustCompile(`(?s)\[([^\]]+)\]\(([^)]+)\)`)
-	s = linkRe.ReplaceAllString(s, "$1 ($2)")
-
-	// Bold: **text** and __text__ (two separate, since Go regexp does not support backreferences)
-	boldRe1 := regexp.MustCompile(`(?s)\*\*(.+?)\*\*`)
-	s = boldRe1.ReplaceAllString(s, "\033[1m$1\033[0m")
-	boldRe2 := regexp.MustCompile(`(?s)__(.+?)__`)
-	s = boldRe2.ReplaceAllString(s, "\033[1m$1\033[0m")
-
-	// Italic: *text* and _text_
-	// Run after bold so **...** / __...__ are already handled.
-	italicRe1 := regexp.MustCompile(`(?s)\*(.+?)\*`)
-	s = italicRe1.ReplaceAllString(s, "\033[3m$1\033[0m")
-	italicRe2 := regexp.MustCompile(`(?s)_(.+?)_`)
-	s = italicRe2.ReplaceAllString(s, "\033[3m$1\033[0m")
-
-	return s
-}